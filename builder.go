@@ -16,8 +16,23 @@ type PromptBuilder interface {
 	ProcessBatchFromDir(directory string) error
 	ProcessBatch(batch [][]Prompt) error
 
+	// ProcessBatchFiltered is ProcessBatch restricted to prompts whose "index/name" matches
+	// pattern, a "/"-separated go-test -run style regex.
+	ProcessBatchFiltered(batch [][]Prompt, pattern string) error
+
 	ProcessFromFile(filename string) ([]Message, error)
 	ProcessRaw(name, prompt string) ([]Message, error)
 
+	// Execute replays a CompiledPrompt produced by Compile, substituting annotations from the
+	// current annotation map, without re-running the lexer over the source string.
+	Execute(cp *CompiledPrompt, params map[string]string) ([]Message, error)
+
+	// Watch observes a prompt directory and re-processes files as they change on disk.
+	Watch(directory string, opts WatchOptions) (stop func(), err error)
+
 	SetAnnotation(id string, value interface{})
+
+	// SetAnnotationValue stores v without flattening it to a string, so @for/@if directives can
+	// inspect its real shape.
+	SetAnnotationValue(id string, v any)
 }
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,18 +12,23 @@ import (
 
 type promptBuilder struct {
 	annotations       map[string]string
+	typedAnnotations  map[string]interface{}
 	annotationsMutexn sync.RWMutex
 	onBeforeProcess   func(name string, index int, params map[string]string) (bool, error)
 	onAfterProcess    func(name string, index int, params map[string]string, messages []Message) error
+	filter            *batchFilter
 }
 
 type PromptBuilderOptions struct {
 	Annotations     map[string]string
 	OnBeforeProcess func(name string, index int, params map[string]string) (skip bool, err error)
 	OnAfterProcess  func(name string, index int, params map[string]string, messages []Message) error
+	// Filter is a -run-style slash-separated regex (e.g. "0/.*validate") applied by
+	// ProcessBatch and ProcessBatchFromDir to every prompt's "index/name".
+	Filter string
 }
 
-func NewPromptBuilder(options ...PromptBuilderOptions) PromptBuilder {
+func NewPromptBuilder(options ...PromptBuilderOptions) (PromptBuilder, error) {
 	// Default annotations
 	internalAnnotations := map[string]string{
 		"OutputSchema": OutputSchema,
@@ -33,6 +37,7 @@ func NewPromptBuilder(options ...PromptBuilderOptions) PromptBuilder {
 	}
 	var onBeforeProcess func(name string, index int, params map[string]string) (bool, error)
 	var onAfterProcess func(name string, index int, params map[string]string, messages []Message) error
+	var filter *batchFilter
 	// Override options
 	if len(options) > 0 {
 		if options[0].Annotations != nil {
@@ -42,9 +47,16 @@ func NewPromptBuilder(options ...PromptBuilderOptions) PromptBuilder {
 		}
 		onBeforeProcess = options[0].OnBeforeProcess
 		onAfterProcess = options[0].OnAfterProcess
+		if options[0].Filter != "" {
+			compiled, err := compileBatchFilter(options[0].Filter)
+			if err != nil {
+				return nil, fmt.Errorf("error compiling filter: %w", err)
+			}
+			filter = compiled
+		}
 	}
 
-	return &promptBuilder{internalAnnotations, sync.RWMutex{}, onBeforeProcess, onAfterProcess}
+	return &promptBuilder{internalAnnotations, map[string]interface{}{}, sync.RWMutex{}, onBeforeProcess, onAfterProcess, filter}, nil
 }
 
 func readFile(filename string) (string, error) {
@@ -127,11 +139,22 @@ func (pB *promptBuilder) ProcessBatchFromDir(directory string) error {
 	}
 
 	// Process batches
-	return pB.ProcessBatch(batches)
+	return pB.processBatch(batches, directory, pB.filter)
 }
 
 func (pB *promptBuilder) ProcessBatch(batch [][]Prompt) error {
-	for _, prompts := range batch {
+	return pB.processBatch(batch, ".", pB.filter)
+}
+
+// processBatch is the shared implementation behind ProcessBatch, ProcessBatchFromDir and
+// ProcessBatchFiltered; it knows the directory the prompts came from so that @include
+// directives resolve correctly, and skips any prompt that filter rejects.
+func (pB *promptBuilder) processBatch(batch [][]Prompt, directory string, filter *batchFilter) error {
+	for batchIndex, prompts := range batch {
+		if !filter.matches(0, strconv.Itoa(batchIndex)) {
+			continue
+		}
+
 		wg := sync.WaitGroup{}
 		errChan := make(chan error, len(prompts))
 		wg.Add(len(prompts))
@@ -140,12 +163,19 @@ func (pB *promptBuilder) ProcessBatch(batch [][]Prompt) error {
 				defer wg.Done()
 				// Remove the .prompt suffix
 				prompts[i].Name = strings.TrimSuffix(prompts[i].Name, ".prompt")
-				// Process the prompt
-				_, err := pB.ProcessRaw(prompts[i].Name, prompts[i].Text)
+				if !filter.matches(1, prompts[i].Name) {
+					return
+				}
+				// Compile and execute the prompt
+				cp, err := compileWithDir(prompts[i].Name, prompts[i].Text, directory)
 				if err != nil {
 					errChan <- fmt.Errorf("error processing prompt: %w", err)
 					return
 				}
+				if _, err := pB.Execute(cp, cp.Params); err != nil {
+					errChan <- fmt.Errorf("error processing prompt: %w", err)
+					return
+				}
 			}(i)
 		}
 		wg.Wait()
@@ -166,8 +196,16 @@ func (pB *promptBuilder) ProcessFromFile(filename string) ([]Message, error) {
 	// Remove the .prompt suffix
 	pathParts := strings.Split(filename, "/")
 	name := strings.TrimSuffix(pathParts[len(pathParts)-1], ".prompt")
-	// Process the file contents
-	messages, err := pB.ProcessRaw(name, text)
+	directory := "."
+	if len(pathParts) > 1 {
+		directory = strings.Join(pathParts[:len(pathParts)-1], "/")
+	}
+	// Compile and execute the file contents, resolving @include relative to its directory
+	cp, err := compileWithDir(name, text, directory)
+	if err != nil {
+		return []Message{}, fmt.Errorf("error processing file: %w", err)
+	}
+	messages, err := pB.Execute(cp, cp.Params)
 	if err != nil {
 		return []Message{}, fmt.Errorf("error processing file: %w", err)
 	}
@@ -190,6 +228,7 @@ func processPrompt(prompt string) ([]Message, map[string]string, error) {
 	var stack int
 	var label string
 	var isTabulated bool
+	var directiveDepth int
 	for i := 0; i < len(prompt); {
 		// Skip to next character
 		next := func(write bool) {
@@ -330,23 +369,55 @@ func processPrompt(prompt string) ([]Message, map[string]string, error) {
 				return []Message{}, params, fmt.Errorf("expected label or constant, found nothing")
 			}
 		}
-		// Annotations
+		// Annotations and block directives (@for/@if/@else/@end)
 		if prompt[i] == '@' {
-			next(true)
-			for prompt[i] >= 'a' && prompt[i] <= 'z' ||
-				prompt[i] >= 'A' && prompt[i] <= 'Z' ||
-				prompt[i] >= '0' && prompt[i] <= '9' ||
-				prompt[i] == '_' || prompt[i] == '-' {
-				next(true)
+			next(false)
+			idStart := i
+			for isDirectiveChar(prompt[i]) {
+				next(false)
 			}
-			// Skip spaces
-			if prompt[i] == '\t' || prompt[i] == ' ' {
-				for prompt[i] == '\t' || prompt[i] == ' ' {
-					next(false)
+			id := prompt[idStart:i]
+			writeNewline := true
+			switch id {
+			case "for":
+				name, varName, err := parseForHeader(prompt, &i, next)
+				if err != nil {
+					return []Message{}, params, err
+				}
+				result.WriteString(forHeaderMarker(name, varName))
+				writeNewline = false
+				directiveDepth++
+			case "if":
+				name, err := parseIfHeader(prompt, &i, next)
+				if err != nil {
+					return []Message{}, params, err
+				}
+				result.WriteString(ifHeaderMarker(name))
+				writeNewline = false
+				directiveDepth++
+			case "else":
+				result.WriteString(elseMarker)
+				writeNewline = false
+			case "end":
+				result.WriteString(endMarker)
+				writeNewline = false
+				if directiveDepth > 0 {
+					directiveDepth--
 				}
+			default:
+				if directiveDepth > 0 {
+					id = appendDottedSuffix(prompt, &i, next, id)
+				}
+				result.WriteString("@" + id)
+			}
+			// Skip spaces
+			for prompt[i] == '\t' || prompt[i] == ' ' {
+				next(false)
+			}
+			if writeNewline {
+				// Add line break to separate annotations
+				result.Write([]byte("\n"))
 			}
-			// Add line break to separate annotations
-			result.Write([]byte("\n"))
 			// Check for new line
 			if prompt[i] == '\n' {
 				continue
@@ -370,23 +441,55 @@ func processPrompt(prompt string) ([]Message, map[string]string, error) {
 			if prompt[i] == '"' {
 				next(true)
 				for prompt[i] != '"' {
-					// Annotations
+					// Annotations and block directives (@for/@if/@else/@end)
 					if prompt[i] == '@' {
-						next(true)
-						for prompt[i] >= 'a' && prompt[i] <= 'z' ||
-							prompt[i] >= 'A' && prompt[i] <= 'Z' ||
-							prompt[i] >= '0' && prompt[i] <= '9' ||
-							prompt[i] == '_' || prompt[i] == '-' {
-							next(true)
+						next(false)
+						idStart := i
+						for isDirectiveChar(prompt[i]) {
+							next(false)
 						}
-						// Skip spaces
-						if prompt[i] == '\t' || prompt[i] == ' ' {
-							for prompt[i] == '\t' || prompt[i] == ' ' {
-								next(false)
+						id := prompt[idStart:i]
+						writeNewline := true
+						switch id {
+						case "for":
+							name, varName, err := parseForHeader(prompt, &i, next)
+							if err != nil {
+								return []Message{}, params, err
 							}
+							result.WriteString(forHeaderMarker(name, varName))
+							writeNewline = false
+							directiveDepth++
+						case "if":
+							name, err := parseIfHeader(prompt, &i, next)
+							if err != nil {
+								return []Message{}, params, err
+							}
+							result.WriteString(ifHeaderMarker(name))
+							writeNewline = false
+							directiveDepth++
+						case "else":
+							result.WriteString(elseMarker)
+							writeNewline = false
+						case "end":
+							result.WriteString(endMarker)
+							writeNewline = false
+							if directiveDepth > 0 {
+								directiveDepth--
+							}
+						default:
+							if directiveDepth > 0 {
+								id = appendDottedSuffix(prompt, &i, next, id)
+							}
+							result.WriteString("@" + id)
+						}
+						// Skip spaces
+						for prompt[i] == '\t' || prompt[i] == ' ' {
+							next(false)
+						}
+						if writeNewline {
+							// Add line break to separate annotations
+							result.Write([]byte("\n"))
 						}
-						// Add line break to separate annotations
-						result.Write([]byte("\n"))
 						// Check for new line
 						if prompt[i] == '\n' {
 							continue
@@ -470,26 +573,26 @@ func processPrompt(prompt string) ([]Message, map[string]string, error) {
 	return messages, params, nil
 }
 
+// ProcessRaw compiles the prompt and immediately executes it once; it is a thin wrapper kept
+// for callers that don't need to reuse the compiled form.
 func (pB *promptBuilder) ProcessRaw(name, prompt string) ([]Message, error) {
-	// Process the prompt
-	results, params, err := processPrompt(prompt)
+	cp, err := Compile(name, prompt)
 	if err != nil {
 		return []Message{}, fmt.Errorf("error processing prompt: %w", err)
 	}
+	return pB.Execute(cp, cp.Params)
+}
 
-	// Remove the prefix from the prompt name
-	index := 0
-	path := strings.Split(name, "/")
-	if parts := strings.Split(path[len(path)-1], "_"); len(parts) > 1 {
-		name = parts[1]
-		index, _ = strconv.Atoi(parts[0])
-	} else {
-		name = parts[0]
+// Execute walks a CompiledPrompt's AST, substituting annotations from the current annotation
+// map, and emits the resulting messages without touching the original source string again.
+func (pB *promptBuilder) Execute(cp *CompiledPrompt, params map[string]string) ([]Message, error) {
+	if params == nil {
+		params = cp.Params
 	}
 
 	// Call onBeforeProcess callback
 	if pB.onBeforeProcess != nil {
-		skip, err := pB.onBeforeProcess(name, index, params)
+		skip, err := pB.onBeforeProcess(cp.Name, cp.Index, params)
 		if err != nil {
 			return []Message{}, fmt.Errorf("error before processing: %w", err)
 		}
@@ -498,23 +601,23 @@ func (pB *promptBuilder) ProcessRaw(name, prompt string) ([]Message, error) {
 		}
 	}
 
-	// Replace annotations
-	annotations := regexp.MustCompile(`@[A-Za-z0-9_-]+\n`)
-	for i, message := range results {
-		results[i].Content = annotations.ReplaceAllStringFunc(message.Content, func(annotation string) string {
-			return pB.getAnnotation(strings.TrimSpace(annotation)[1:])
-		})
+	messages := make([]Message, len(cp.messages))
+	for i, message := range cp.messages {
+		var content strings.Builder
+		if err := renderSegments(pB, message.segments, nil, &content); err != nil {
+			return []Message{}, fmt.Errorf("error rendering message %q: %w", message.role, err)
+		}
+		messages[i] = Message{Role: message.role, Content: content.String()}
 	}
 
 	// Call onAfterProcess callback
 	if pB.onAfterProcess != nil {
-		err := pB.onAfterProcess(name, index, params, results)
-		if err != nil {
+		if err := pB.onAfterProcess(cp.Name, cp.Index, params, messages); err != nil {
 			return []Message{}, fmt.Errorf("error after processing: %w", err)
 		}
 	}
 
-	return results, nil
+	return messages, nil
 }
 
 func (pB *promptBuilder) SetAnnotation(id string, value interface{}) {
@@ -531,3 +634,22 @@ func (pB *promptBuilder) SetAnnotation(id string, value interface{}) {
 		pB.annotations[id] = string(valueJSON)
 	}
 }
+
+// SetAnnotationValue stores v as-is instead of flattening it to a string, so @for/@if
+// directives can inspect its real shape (slice, map, struct) at Execute time.
+func (pB *promptBuilder) SetAnnotationValue(id string, v any) {
+	pB.annotationsMutexn.Lock()
+	defer pB.annotationsMutexn.Unlock()
+	if v == nil {
+		delete(pB.typedAnnotations, id)
+		return
+	}
+	pB.typedAnnotations[id] = v
+}
+
+func (pB *promptBuilder) getTypedAnnotation(id string) (interface{}, bool) {
+	pB.annotationsMutexn.RLock()
+	defer pB.annotationsMutexn.RUnlock()
+	value, ok := pB.typedAnnotations[id]
+	return value, ok
+}
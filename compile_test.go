@@ -0,0 +1,90 @@
+package poggers
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestExecuteReplaysCompiledPromptWithoutRecompiling(t *testing.T) {
+	cp, err := Compile("greeting", "system:\n\t@Name\n")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	pb, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+	pb.SetAnnotationValue("Name", "Alice")
+
+	first, err := pb.Execute(cp, cp.Params)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if first[0].Content != "Alice" {
+		t.Fatalf("expected %q, got %q", "Alice", first[0].Content)
+	}
+
+	// Re-executing the same CompiledPrompt after the annotation changes must reflect the new
+	// value without Compile being called again.
+	pb.SetAnnotationValue("Name", "Bob")
+	second, err := pb.Execute(cp, cp.Params)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if second[0].Content != "Bob" {
+		t.Fatalf("expected %q, got %q", "Bob", second[0].Content)
+	}
+}
+
+func TestExecuteIsSafeForConcurrentReuse(t *testing.T) {
+	cp, err := Compile("greeting", "system:\n\t@Name\n")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	pb, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+	pb.SetAnnotationValue("Name", "Alice")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			messages, err := pb.Execute(cp, cp.Params)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if messages[0].Content != "Alice" {
+				errs <- fmt.Errorf("expected %q, got %q", "Alice", messages[0].Content)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent Execute failed: %v", err)
+		}
+	}
+}
+
+func TestProcessRawIsAThinCompileAndExecuteWrapper(t *testing.T) {
+	pb, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+	messages, err := pb.ProcessRaw("greeting", "system:\n\tliteral body\n")
+	if err != nil {
+		t.Fatalf("ProcessRaw: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Content != "literal body" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+}
@@ -0,0 +1,105 @@
+package poggers
+
+import "fmt"
+
+// Block directives (@for/@if/@else/@end) are lexed like any other annotation but, instead of
+// being written back as literal "@id\n" text, are recorded as NUL-delimited sentinel markers
+// that can't occur in real prompt source. splitSegments later turns these markers into a
+// proper segment tree without needing a second trip through the character-by-character lexer.
+const (
+	forMarkerOpen  = "\x00FOR\x00"
+	forMarkerAs    = "\x00AS\x00"
+	forMarkerClose = "\x00FOREND\x00"
+	ifMarkerOpen   = "\x00IF\x00"
+	ifMarkerClose  = "\x00IFEND\x00"
+	elseMarker     = "\x00ELSE\x00"
+	endMarker      = "\x00END\x00"
+)
+
+func forHeaderMarker(name, varName string) string {
+	return forMarkerOpen + name + forMarkerAs + varName + forMarkerClose
+}
+
+func ifHeaderMarker(name string) string {
+	return ifMarkerOpen + name + ifMarkerClose
+}
+
+// isDirectiveChar reports whether c can appear in an annotation or block-directive identifier.
+func isDirectiveChar(c byte) bool {
+	return c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' ||
+		c == '_' || c == '-'
+}
+
+// appendDottedSuffix extends id with any ".Field" (or ".Field.Nested") suffix, so a plain
+// annotation reference like `@x.Field` resolves as a single dotted id. This is only applied
+// inside a @for/@if body (see directiveDepth in processPrompt) so that in prose like
+// "foo@bar.com" outside a block directive the ".com" stays literal text; the "@bar" part is
+// still read as a plain annotation.
+func appendDottedSuffix(prompt string, i *int, next func(bool), id string) string {
+	for prompt[*i] == '.' {
+		start := *i
+		next(false)
+		for isDirectiveChar(prompt[*i]) {
+			next(false)
+		}
+		if *i == start+1 {
+			// Lone trailing dot with no field name; leave it for the literal scanner.
+			*i = start
+			break
+		}
+		id += prompt[start:*i]
+	}
+	return id
+}
+
+// skipHSpace advances i past any run of tabs/spaces, using next so the caller's read cursor
+// stays in sync.
+func skipHSpace(prompt string, i *int, next func(bool)) {
+	for prompt[*i] == '\t' || prompt[*i] == ' ' {
+		next(false)
+	}
+}
+
+// readDirectiveIdent consumes an identifier (as accepted by isDirectiveChar) starting at *i.
+func readDirectiveIdent(prompt string, i *int, next func(bool)) string {
+	start := *i
+	for isDirectiveChar(prompt[*i]) {
+		next(false)
+	}
+	return prompt[start:*i]
+}
+
+// parseForHeader parses the `Name as x` clause of an `@for Name as x` directive. i points just
+// past the "for" keyword.
+func parseForHeader(prompt string, i *int, next func(bool)) (name, varName string, err error) {
+	skipHSpace(prompt, i, next)
+	name = readDirectiveIdent(prompt, i, next)
+	if name == "" {
+		return "", "", fmt.Errorf("expected an annotation name after @for")
+	}
+	skipHSpace(prompt, i, next)
+	if *i+2 > len(prompt) || prompt[*i:*i+2] != "as" ||
+		(*i+2 < len(prompt) && isDirectiveChar(prompt[*i+2])) {
+		return "", "", fmt.Errorf("expected %q after @for %s", "as", name)
+	}
+	*i += 2
+	skipHSpace(prompt, i, next)
+	varName = readDirectiveIdent(prompt, i, next)
+	if varName == "" {
+		return "", "", fmt.Errorf("expected a loop variable after @for %s as", name)
+	}
+	return name, varName, nil
+}
+
+// parseIfHeader parses the `Name` clause of an `@if Name` directive. i points just past the
+// "if" keyword.
+func parseIfHeader(prompt string, i *int, next func(bool)) (name string, err error) {
+	skipHSpace(prompt, i, next)
+	name = readDirectiveIdent(prompt, i, next)
+	if name == "" {
+		return "", fmt.Errorf("expected an annotation name after @if")
+	}
+	return name, nil
+}
@@ -0,0 +1,53 @@
+package poggers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// batchFilter applies go test -run style matching to ProcessBatch: the pattern is split on
+// "/", each segment is compiled as its own regex, and each nesting level (batch index, then
+// prompt name) is matched against the corresponding segment. A level with no corresponding
+// segment matches everything.
+type batchFilter struct {
+	segments []*regexp.Regexp
+}
+
+// compileBatchFilter compiles pattern into a batchFilter. An empty pattern matches everything
+// and returns a nil filter.
+func compileBatchFilter(pattern string) (*batchFilter, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	parts := strings.Split(pattern, "/")
+	segments := make([]*regexp.Regexp, len(parts))
+	for i, part := range parts {
+		re, err := regexp.Compile(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter segment %q: %w", part, err)
+		}
+		segments[i] = re
+	}
+	return &batchFilter{segments: segments}, nil
+}
+
+// matches reports whether value matches the filter at the given nesting level (0 = batch
+// index, 1 = prompt name). A nil filter, or a level beyond the pattern's segments, matches
+// everything.
+func (f *batchFilter) matches(level int, value string) bool {
+	if f == nil || level >= len(f.segments) {
+		return true
+	}
+	return f.segments[level].MatchString(value)
+}
+
+// ProcessBatchFiltered is ProcessBatch restricted to prompts whose "index/name" matches
+// pattern, without needing to comment out or duplicate the rest of the batch on disk.
+func (pB *promptBuilder) ProcessBatchFiltered(batch [][]Prompt, pattern string) error {
+	filter, err := compileBatchFilter(pattern)
+	if err != nil {
+		return fmt.Errorf("error compiling filter: %w", err)
+	}
+	return pB.processBatch(batch, ".", filter)
+}
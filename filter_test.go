@@ -0,0 +1,52 @@
+package poggers
+
+import "testing"
+
+func TestCompileBatchFilterEmptyPatternMatchesEverything(t *testing.T) {
+	filter, err := compileBatchFilter("")
+	if err != nil {
+		t.Fatalf("compileBatchFilter: %v", err)
+	}
+	if filter != nil {
+		t.Fatalf("expected an empty pattern to yield a nil filter, got %+v", filter)
+	}
+	if !filter.matches(0, "anything") || !filter.matches(1, "anything") {
+		t.Fatal("expected a nil filter to match everything")
+	}
+}
+
+func TestCompileBatchFilterInvalidSegmentErrors(t *testing.T) {
+	if _, err := compileBatchFilter("checkout/(unterminated"); err == nil {
+		t.Fatal("expected an invalid regex segment to return an error")
+	}
+}
+
+func TestBatchFilterSegmentMatching(t *testing.T) {
+	filter, err := compileBatchFilter("0/.*validate")
+	if err != nil {
+		t.Fatalf("compileBatchFilter: %v", err)
+	}
+
+	if !filter.matches(0, "0") {
+		t.Fatal("expected batch index 0 to match segment 0")
+	}
+	if filter.matches(0, "1") {
+		t.Fatal("expected batch index 1 not to match segment 0")
+	}
+	if !filter.matches(1, "checkout_validate") {
+		t.Fatal("expected a name containing \"validate\" to match segment 1")
+	}
+	if filter.matches(1, "checkout_submit") {
+		t.Fatal("expected a name without \"validate\" not to match segment 1")
+	}
+	// A level beyond the pattern's segments matches everything.
+	if !filter.matches(2, "anything") {
+		t.Fatal("expected a nesting level past the pattern's segments to match everything")
+	}
+}
+
+func TestNewPromptBuilderRejectsInvalidFilter(t *testing.T) {
+	if _, err := NewPromptBuilder(PromptBuilderOptions{Filter: "checkout/(unterminated"}); err == nil {
+		t.Fatal("expected an invalid Filter pattern to surface an error from NewPromptBuilder")
+	}
+}
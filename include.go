@@ -0,0 +1,100 @@
+package poggers
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// includeDirective matches a top-level `@include "path"` (or `@import "path"`) line, leading
+// tabulation and all, so an include can appear inside a label body.
+var includeDirective = regexp.MustCompile(`(?m)^[ \t]*@(?:include|import)\s+"([^"]+)"[ \t]*$`)
+
+// topLevelLabel matches a label definition at the very start of a line, the same grammar
+// processPrompt uses to end the message body in progress and start a new one.
+var topLevelLabel = regexp.MustCompile(`(?m)^[A-Za-z0-9_-]+:`)
+
+// resolveIncludes inlines every @include/@import directive found in text, resolving each path
+// (which may be a glob) relative to baseDir. visited guards against cycles along the current
+// include chain; it is copied rather than shared so that sibling includes don't spuriously
+// trip each other's cycle detection.
+//
+// An included file that only contains tabulated body text (no top-level label of its own)
+// becomes a partial that appends to the caller's current label, as intended. An included file
+// that defines its own top-level label is only safe to inline when it is the last thing in the
+// caller's label body; see the hasTrailingContent check below for why.
+func resolveIncludes(text, baseDir string, visited map[string]bool) (string, error) {
+	matches := includeDirective.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, nil
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, m := range matches {
+		result.WriteString(text[last:m[0]])
+		last = m[1]
+
+		pattern := text[m[2]:m[3]]
+		paths, err := filepath.Glob(filepath.Join(baseDir, pattern))
+		if err != nil {
+			return "", fmt.Errorf("error resolving include %q: %w", pattern, err)
+		}
+		if len(paths) == 0 {
+			return "", fmt.Errorf("include %q did not match any files", pattern)
+		}
+
+		// If the including label body has more content after this directive, an included file
+		// that defines its own top-level label would silently reparent that trailing content
+		// under its own last label instead of appending to the caller's, since includes are
+		// inlined as raw text before the label grammar is parsed. Catch that as an error rather
+		// than let it happen silently.
+		trailing := text[last:]
+		if idx := topLevelLabel.FindStringIndex(trailing); idx != nil {
+			trailing = trailing[:idx[0]]
+		}
+		hasTrailingContent := strings.TrimSpace(trailing) != ""
+
+		for _, path := range paths {
+			expanded, err := expandInclude(path, visited)
+			if err != nil {
+				return "", err
+			}
+			if hasTrailingContent && topLevelLabel.MatchString(expanded) {
+				return "", fmt.Errorf("include %q defines its own top-level label and is not the last content in its including label body; move it to the end of the body, or make it a tabulated partial fragment instead", path)
+			}
+			result.WriteString(expanded)
+			if !strings.HasSuffix(expanded, "\n") {
+				result.WriteByte('\n')
+			}
+		}
+	}
+	result.WriteString(text[last:])
+
+	return result.String(), nil
+}
+
+// expandInclude reads and recursively resolves a single included file.
+func expandInclude(path string, visited map[string]bool) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving include %q: %w", path, err)
+	}
+	if visited[absPath] {
+		return "", fmt.Errorf("cyclic @include detected for %q", path)
+	}
+
+	text, err := readFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading include %q: %w", path, err)
+	}
+
+	childVisited := make(map[string]bool, len(visited)+1)
+	for k := range visited {
+		childVisited[k] = true
+	}
+	childVisited[absPath] = true
+
+	return resolveIncludes(text, filepath.Dir(path), childVisited)
+}
@@ -0,0 +1,54 @@
+package poggers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncludeCycleDetected(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.prompt"), []byte("\tbefore\n\t@include \"b.prompt\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.prompt"), []byte("\tfrom b\n\t@include \"a.prompt\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := os.ReadFile(filepath.Join(dir, "a.prompt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, compileErr := compileWithDir("a", string(text), dir)
+	if compileErr == nil || !strings.Contains(compileErr.Error(), "cyclic") {
+		t.Fatalf("expected a cyclic include error, got %v", compileErr)
+	}
+}
+
+func TestIncludePartialAppendsToCallerLabel(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shared.prompt"), []byte("\tshared body\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := compileWithDir("main", "system:\n\tbefore\n\t@include \"shared.prompt\"\n\tafter\n", dir)
+	if err != nil {
+		t.Fatalf("compileWithDir: %v", err)
+	}
+	if len(cp.messages) != 1 {
+		t.Fatalf("expected the partial to append to the caller's single system message, got %d messages", len(cp.messages))
+	}
+}
+
+func TestIncludeOwnLabelHijackIsRejected(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "full.prompt"), []byte("other:\n\tother body\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := compileWithDir("main", "system:\n\t@include \"full.prompt\"\n\tafter include\n", dir)
+	if err == nil || !strings.Contains(err.Error(), "top-level label") {
+		t.Fatalf("expected an error about the include's own top-level label hijacking trailing content, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package poggers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// renderSegments writes segments to out, resolving annotations (and @for/@if blocks) against
+// pB's annotation maps and the loop-variable bindings currently in scope (env).
+func renderSegments(pB *promptBuilder, segments []segment, env map[string]interface{}, out *strings.Builder) error {
+	for _, seg := range segments {
+		switch seg.kind {
+		case segmentLiteral:
+			out.WriteString(seg.value)
+
+		case segmentAnnotation:
+			text, err := renderAnnotationText(pB, seg.value, env)
+			if err != nil {
+				return err
+			}
+			out.WriteString(text)
+
+		case segmentFor:
+			value, ok := resolveAnnotationValue(pB, seg.value, env)
+			if !ok {
+				continue
+			}
+			rv := reflect.ValueOf(value)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				return fmt.Errorf("@for %s: annotation is not a list", seg.value)
+			}
+			for i := 0; i < rv.Len(); i++ {
+				child := make(map[string]interface{}, len(env)+1)
+				for k, v := range env {
+					child[k] = v
+				}
+				child[seg.varName] = rv.Index(i).Interface()
+				if err := renderSegments(pB, seg.body, child, out); err != nil {
+					return err
+				}
+			}
+
+		case segmentIf:
+			value, ok := resolveAnnotationValue(pB, seg.value, env)
+			branch := seg.body
+			if !ok || !truthy(value) {
+				branch = seg.elseBody
+			}
+			if err := renderSegments(pB, branch, env, out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveAnnotationValue resolves a (possibly dotted, e.g. "x.Field") annotation reference
+// against the current loop-variable bindings first, then the builder's typed and string
+// annotations.
+func resolveAnnotationValue(pB *promptBuilder, id string, env map[string]interface{}) (interface{}, bool) {
+	parts := strings.Split(id, ".")
+	root := parts[0]
+
+	value, ok := env[root]
+	if !ok {
+		value, ok = pB.getTypedAnnotation(root)
+	}
+	if !ok {
+		if str := pB.getAnnotation(root); str != "" {
+			value, ok = str, true
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+
+	for _, field := range parts[1:] {
+		value, ok = resolveField(value, field)
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// resolveField looks up a struct field or map key on value via reflection.
+func resolveField(value interface{}, field string) (interface{}, bool) {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		fv := rv.FieldByName(field)
+		if !fv.IsValid() || !fv.CanInterface() {
+			return nil, false
+		}
+		return fv.Interface(), true
+	case reflect.Map:
+		key := reflect.ValueOf(field)
+		if !key.Type().AssignableTo(rv.Type().Key()) {
+			return nil, false
+		}
+		fv := rv.MapIndex(key)
+		if !fv.IsValid() {
+			return nil, false
+		}
+		return fv.Interface(), true
+	default:
+		return nil, false
+	}
+}
+
+// renderAnnotationText renders a plain `@Name` or `@x.Field` placeholder to text.
+func renderAnnotationText(pB *promptBuilder, id string, env map[string]interface{}) (string, error) {
+	value, ok := resolveAnnotationValue(pB, id, env)
+	if !ok {
+		return "", nil
+	}
+	if str, isString := value.(string); isString {
+		return str, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value), nil
+	}
+	return string(encoded), nil
+}
+
+// truthy decides whether an @if condition holds based on the annotation's actual type.
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !rv.IsNil()
+	}
+	return true
+}
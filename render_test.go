@@ -0,0 +1,49 @@
+package poggers
+
+import (
+	"strings"
+	"testing"
+)
+
+type reflectItem struct {
+	Name   string
+	secret string
+}
+
+func TestForLoopSkipsUnexportedField(t *testing.T) {
+	builder, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+	pb := builder.(*promptBuilder)
+	pb.SetAnnotationValue("Items", []reflectItem{{Name: "a", secret: "s1"}})
+
+	cp, err := Compile("loop", "system:\n\t@for Items as x\n\t- @x.secret\n\t@end\n")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if _, err := pb.Execute(cp, cp.Params); err != nil {
+		t.Fatalf("Execute panicked/errored on an unexported field instead of treating it as not-found: %v", err)
+	}
+}
+
+func TestForLoopResolvesExportedField(t *testing.T) {
+	builder, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+	pb := builder.(*promptBuilder)
+	pb.SetAnnotationValue("Items", []reflectItem{{Name: "a", secret: "s1"}})
+
+	cp, err := Compile("loop", "system:\n\t@for Items as x\n\t- @x.Name\n\t@end\n")
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	messages, err := pb.Execute(cp, cp.Params)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := messages[0].Content; !strings.Contains(got, "a") {
+		t.Fatalf("expected rendered content to contain the resolved field value %q, got %q", "a", got)
+	}
+}
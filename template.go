@@ -0,0 +1,163 @@
+package poggers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	segmentLiteral segmentKind = iota
+	segmentAnnotation
+	segmentFor
+	segmentIf
+)
+
+// segment is one node inside a compiled message: a literal run, an annotation placeholder, or
+// a @for/@if block with its own nested body.
+type segment struct {
+	kind  segmentKind
+	value string // literal text, the annotation id, or the @for/@if annotation name
+
+	varName  string    // @for only: the loop variable introduced by "as x"
+	body     []segment // @for: loop body. @if: the "then" branch
+	elseBody []segment // @if only: the "@else" branch, if any
+}
+
+// compiledMessage is a message whose content has already been split into segments.
+type compiledMessage struct {
+	role     string
+	segments []segment
+}
+
+// CompiledPrompt is the parsed form of a `.prompt` source, produced once by Compile and
+// replayed by PromptBuilder.Execute without ever touching the source string again.
+type CompiledPrompt struct {
+	Name   string
+	Index  int
+	Params map[string]string
+
+	messages []compiledMessage
+}
+
+// Compile runs the lexer once over text and stores the result as a reusable AST. It is the
+// foundation ProcessRaw and ProcessBatch are now built on top of. @include directives are
+// resolved relative to the current working directory; use compileWithDir when the prompt came
+// from a known file so includes resolve relative to that file instead.
+func Compile(name, text string) (*CompiledPrompt, error) {
+	return compileWithDir(name, text, ".")
+}
+
+// compileWithDir is like Compile but resolves @include directives relative to baseDir.
+func compileWithDir(name, text, baseDir string) (*CompiledPrompt, error) {
+	expanded, err := resolveIncludes(text, baseDir, map[string]bool{})
+	if err != nil {
+		return nil, fmt.Errorf("error resolving includes: %w", err)
+	}
+
+	results, params, err := processPrompt(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling prompt: %w", err)
+	}
+
+	// Remove the prefix from the prompt name, same convention ProcessRaw has always used.
+	index := 0
+	path := strings.Split(name, "/")
+	if parts := strings.Split(path[len(path)-1], "_"); len(parts) > 1 {
+		name = parts[1]
+		index, _ = strconv.Atoi(parts[0])
+	} else {
+		name = parts[0]
+	}
+
+	messages := make([]compiledMessage, len(results))
+	for i, message := range results {
+		segments, err := splitSegments(message.Content)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling prompt: %w", err)
+		}
+		messages[i] = compiledMessage{role: message.Role, segments: segments}
+	}
+
+	return &CompiledPrompt{Name: name, Index: index, Params: params, messages: messages}, nil
+}
+
+// blockToken matches the sentinel markers processPrompt emits for @for/@if/@else/@end, plus
+// plain annotation placeholders, in one pass so nesting can be resolved linearly.
+//
+// Groups: 1=annotation, 2=for-name, 3=for-var, 4=if-name, 5=@else, 6=@end.
+var blockToken = regexp.MustCompile(
+	`(@[A-Za-z0-9_.-]+\n)` +
+		`|` + forMarkerOpen + `([^\x00]*)` + forMarkerAs + `([^\x00]*)` + forMarkerClose +
+		`|` + ifMarkerOpen + `([^\x00]*)` + ifMarkerClose +
+		`|(` + elseMarker + `)` +
+		`|(` + endMarker + `)`,
+)
+
+// splitSegments breaks already-lexed message content into a segment tree: literal runs,
+// annotation placeholders, and nested @for/@if blocks. Execute walks this tree without ever
+// re-scanning the original prompt source.
+func splitSegments(content string) ([]segment, error) {
+	type frame struct {
+		seg    segment
+		inElse bool
+	}
+
+	var root []segment
+	var stack []*frame
+
+	appendSegment := func(s segment) {
+		if len(stack) == 0 {
+			root = append(root, s)
+			return
+		}
+		top := stack[len(stack)-1]
+		if top.inElse {
+			top.seg.elseBody = append(top.seg.elseBody, s)
+		} else {
+			top.seg.body = append(top.seg.body, s)
+		}
+	}
+
+	lastEnd := 0
+	for _, m := range blockToken.FindAllStringSubmatchIndex(content, -1) {
+		if m[0] > lastEnd {
+			appendSegment(segment{kind: segmentLiteral, value: content[lastEnd:m[0]]})
+		}
+		switch {
+		case m[2] >= 0: // annotation
+			id := strings.TrimSpace(content[m[2]:m[3]])[1:]
+			appendSegment(segment{kind: segmentAnnotation, value: id})
+		case m[4] >= 0: // @for name as var
+			stack = append(stack, &frame{seg: segment{kind: segmentFor, value: content[m[4]:m[5]], varName: content[m[6]:m[7]]}})
+		case m[8] >= 0: // @if name
+			stack = append(stack, &frame{seg: segment{kind: segmentIf, value: content[m[8]:m[9]]}})
+		case m[10] >= 0: // @else
+			if len(stack) > 0 {
+				stack[len(stack)-1].inElse = true
+			}
+		case m[12] >= 0: // @end
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				appendSegment(top.seg)
+			}
+		}
+		lastEnd = m[1]
+	}
+	if lastEnd < len(content) {
+		appendSegment(segment{kind: segmentLiteral, value: content[lastEnd:]})
+	}
+	if len(stack) > 0 {
+		unclosed := stack[len(stack)-1].seg
+		directive := "@if"
+		if unclosed.kind == segmentFor {
+			directive = "@for"
+		}
+		return nil, fmt.Errorf("unterminated %s %s: missing @end", directive, unclosed.value)
+	}
+	return root, nil
+}
@@ -0,0 +1,41 @@
+package poggers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnterminatedForReturnsError(t *testing.T) {
+	_, err := Compile("loop", "system:\n\t@for Items as x\n\t- @x\n")
+	if err == nil {
+		t.Fatal("expected an error for a @for with no matching @end, got nil")
+	}
+}
+
+func TestUnterminatedIfReturnsError(t *testing.T) {
+	_, err := Compile("cond", "system:\n\t@if Flag\n\tbody\n")
+	if err == nil {
+		t.Fatal("expected an error for an @if with no matching @end, got nil")
+	}
+}
+
+func TestForHeaderRequiresAsWordBoundary(t *testing.T) {
+	if _, err := Compile("loop", "system:\n\t@for Items asx\n\t@x\n\t@end\n"); err == nil {
+		t.Fatal("expected \"asx\" to be rejected as malformed @for syntax instead of misparsing \"as\" + var \"x\"")
+	}
+}
+
+func TestPlainAnnotationDotNotConsumedOutsideBlock(t *testing.T) {
+	pb, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+	messages, err := pb.ProcessRaw("contact", "system:\n\tcontact me at foo@bar.com please\n")
+	if err != nil {
+		t.Fatalf("ProcessRaw: %v", err)
+	}
+	got := messages[0].Content
+	if !strings.Contains(got, ".com please") {
+		t.Fatalf("expected the dot in a plain email-like annotation reference to remain literal text, got %q", got)
+	}
+}
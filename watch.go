@@ -0,0 +1,128 @@
+package poggers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// Debounce coalesces the multiple filesystem events editors typically emit per save into a
+	// single re-process. Defaults to 200ms when zero.
+	Debounce time.Duration
+	// OnChange is invoked after a watched file settles and is re-processed. index is the batch
+	// prefix parsed from the filename (e.g. "0_checkout.prompt"), or 0 if there is none.
+	OnChange func(name string, index int, messages []Message, err error)
+}
+
+// Watch observes directory (and its subdirectories) for `.prompt` file changes using fsnotify,
+// re-processing affected files and delivering the result to opts.OnChange. It returns a stop
+// func that tears down the watcher.
+func (pB *promptBuilder) Watch(directory string, opts WatchOptions) (stop func(), err error) {
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = 200 * time.Millisecond
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error creating watcher: %w", err)
+	}
+
+	if err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	}); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("error watching directory: %w", err)
+	}
+
+	var mu sync.Mutex
+	timers := map[string]*time.Timer{}
+	done := make(chan struct{})
+
+	process := func(path string) {
+		if !strings.HasSuffix(path, ".prompt") {
+			return
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".prompt")
+		index := 0
+		if parts := strings.SplitN(name, "_", 2); len(parts) > 1 {
+			if n, convErr := strconv.Atoi(parts[0]); convErr == nil {
+				index = n
+				name = parts[1]
+			}
+		}
+
+		messages, err := pB.ProcessFromFile(path)
+		if opts.OnChange != nil {
+			opts.OnChange(name, index, messages, err)
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				// Newly created directories need their own watch to pick up nested files.
+				if event.Op&fsnotify.Create == fsnotify.Create {
+					if info, statErr := os.Stat(event.Name); statErr == nil && info.IsDir() {
+						watcher.Add(event.Name)
+						continue
+					}
+				}
+
+				if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				path := event.Name
+				mu.Lock()
+				if timer, ok := timers[path]; ok {
+					timer.Stop()
+				}
+				timers[path] = time.AfterFunc(debounce, func() { process(path) })
+				mu.Unlock()
+			case watchErr, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				if opts.OnChange != nil {
+					opts.OnChange("", 0, nil, fmt.Errorf("watch error: %w", watchErr))
+				}
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+	stop = func() {
+		stopOnce.Do(func() {
+			close(done)
+			watcher.Close()
+			mu.Lock()
+			for _, timer := range timers {
+				timer.Stop()
+			}
+			mu.Unlock()
+		})
+	}
+	return stop, nil
+}
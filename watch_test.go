@@ -0,0 +1,116 @@
+package poggers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchDebouncesRapidWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.prompt")
+	if err := os.WriteFile(path, []byte("system:\n\tv0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pb, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+
+	var mu sync.Mutex
+	calls := 0
+	stop, err := pb.Watch(dir, WatchOptions{
+		Debounce: 100 * time.Millisecond,
+		OnChange: func(name string, index int, messages []Message, err error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	defer stop()
+
+	// Several rapid writes within the debounce window should coalesce into a single OnChange,
+	// the same way editors emitting multiple save events are meant to be handled.
+	for i := 1; i <= 5; i++ {
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("system:\n\tv%d\n", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	mu.Lock()
+	got := calls
+	mu.Unlock()
+	if got != 1 {
+		t.Fatalf("expected rapid writes to coalesce into 1 OnChange call, got %d", got)
+	}
+}
+
+func TestWatchStopIsIdempotent(t *testing.T) {
+	dir := t.TempDir()
+
+	pb, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+
+	stop, err := pb.Watch(dir, WatchOptions{})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	stop()
+	stop() // must not panic with "close of closed channel"
+}
+
+func TestWatchStopCancelsPendingDebounceTimer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.prompt")
+	if err := os.WriteFile(path, []byte("system:\n\tv0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pb, err := NewPromptBuilder()
+	if err != nil {
+		t.Fatalf("NewPromptBuilder: %v", err)
+	}
+
+	var mu sync.Mutex
+	calls := 0
+	stop, err := pb.Watch(dir, WatchOptions{
+		Debounce: 300 * time.Millisecond,
+		OnChange: func(name string, index int, messages []Message, err error) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("system:\n\tv1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Stop before the debounce window elapses; the pending timer must not fire afterwards.
+	time.Sleep(20 * time.Millisecond)
+	stop()
+
+	time.Sleep(500 * time.Millisecond)
+
+	mu.Lock()
+	got := calls
+	mu.Unlock()
+	if got != 0 {
+		t.Fatalf("expected stop() to cancel the pending debounce timer, got %d OnChange calls", got)
+	}
+}